@@ -0,0 +1,120 @@
+// Package v1alpha1 contains the types for the measure.slok.dev/v1alpha1 API
+// group, the ServiceLevel custom resource used to describe the SLOs the
+// operator measures and exposes.
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServiceLevel represents the required configuration to measure and expose
+// one or more SLOs.
+type ServiceLevel struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ServiceLevelSpec `json:"spec,omitempty"`
+}
+
+// ServiceLevelSpec is the spec for a ServiceLevel resource.
+type ServiceLevelSpec struct {
+	// ServiceLevelName is the service level name used on the outputs, if
+	// not set the ServiceLevel object name will be used.
+	ServiceLevelName string `json:"serviceLevelName,omitempty"`
+	// SLOs are the SLOs that belong to this service level.
+	SLOs []SLO `json:"slos,omitempty"`
+	// CommonLabels are extra labels merged into every child SLO's output.
+	// Where a child SLO's own Prometheus labels set the same key, the SLO's
+	// value wins. They exist so all SLOs
+	// under the same ServiceLevel share the same label dimensions (e.g a
+	// `team` label) without having to repeat it on each SLO's output.
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+}
+
+// SLO represents an SLO that will be measured and exposed using the
+// configured output/s.
+type SLO struct {
+	// Name is the name of the SLO.
+	Name string `json:"name,omitempty"`
+	// AvailabilityObjectivePercent is the percentage (0-100) of success
+	// required to fulfill the SLO (e.g 99.9).
+	AvailabilityObjectivePercent float64 `json:"availabilityObjectivePercent,omitempty"`
+	// Output is the output/s where this SLO measurements will be exposed.
+	Output Output `json:"output,omitempty"`
+}
+
+// Output is where an SLO result will be exposed, more than one output
+// source can be set so the same result is exposed on all of them.
+type Output struct {
+	Prometheus  *PrometheusOutputSource  `json:"prometheus,omitempty"`
+	StatsD      *StatsDOutputSource      `json:"statsd,omitempty"`
+	RemoteWrite *RemoteWriteOutputSource `json:"remoteWrite,omitempty"`
+	OTLP        *OTLPOutputSource        `json:"otlp,omitempty"`
+}
+
+// PrometheusOutputSource is the configuration for the Prometheus output.
+type PrometheusOutputSource struct {
+	// Labels are extra labels that will be added to the exposed metrics of
+	// this SLO.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// StatsDOutputSource is the configuration for the StatsD/DogStatsD output.
+type StatsDOutputSource struct {
+	// Host is the StatsD/DogStatsD daemon host.
+	Host string `json:"host,omitempty"`
+	// Port is the StatsD/DogStatsD daemon port.
+	Port int `json:"port,omitempty"`
+	// Prefix is prepended to every metric name sent to the daemon.
+	Prefix string `json:"prefix,omitempty"`
+	// SampleRate is the sample rate (0-1) used when sending the metrics,
+	// defaults to 1 (no sampling).
+	SampleRate float64 `json:"sampleRate,omitempty"`
+	// Tags are extra DogStatsD tags (`key:value`) added to every metric sent
+	// for this SLO, on top of the per-SLO `service_level`, `namespace` and
+	// `slo` tags the output always sets.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// RemoteWriteOutputSource is the configuration for the Prometheus Remote
+// Write output.
+type RemoteWriteOutputSource struct {
+	// URL is the remote-write endpoint (e.g `https://remote.example.com/api/v1/write`).
+	URL string `json:"url,omitempty"`
+	// BasicAuth is the basic auth credentials used against the endpoint, if
+	// required.
+	BasicAuth *BasicAuth `json:"basicAuth,omitempty"`
+	// BearerToken is the bearer token used against the endpoint, if required.
+	BearerToken string `json:"bearerToken,omitempty"`
+	// TLS is the TLS configuration used against the endpoint.
+	TLS *TLSConfig `json:"tls,omitempty"`
+}
+
+// BasicAuth are the username/password credentials used on an HTTP request.
+type BasicAuth struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// TLSConfig is the TLS configuration used on an HTTP client.
+type TLSConfig struct {
+	CAFile             string `json:"caFile,omitempty"`
+	CertFile           string `json:"certFile,omitempty"`
+	KeyFile            string `json:"keyFile,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+// OTLPOutputSource is the configuration for the OTLP output.
+type OTLPOutputSource struct {
+	// Endpoint is the OTLP collector endpoint (host:port).
+	Endpoint string `json:"endpoint,omitempty"`
+	// Protocol selects the OTLP transport, one of `grpc` or `http`, defaults
+	// to `grpc`.
+	Protocol string `json:"protocol,omitempty"`
+	// Insecure disables TLS when talking to the endpoint.
+	Insecure bool `json:"insecure,omitempty"`
+	// NormalizePrometheusNames toggles Prometheus-style name normalization
+	// (dropping the `_total` suffix and keeping snake_case) so the produced
+	// metric names look idiomatic on OTel-native backends.
+	NormalizePrometheusNames bool `json:"normalizePrometheusNames,omitempty"`
+}
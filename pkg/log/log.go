@@ -0,0 +1,26 @@
+// Package log provides the logging abstraction used by the rest of the
+// service-level-operator packages so they don't depend on a concrete
+// logging library.
+package log
+
+// Logger is the interface used to log messages around the application.
+type Logger interface {
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Debugf(format string, args ...interface{})
+	// With returns a new Logger with the given keyvals added to every
+	// subsequent log line.
+	With(keyvals ...interface{}) Logger
+}
+
+// Dummy logger doesn't log anything, it's used mainly on tests.
+var Dummy = &dummy{}
+
+type dummy struct{}
+
+func (dummy) Infof(format string, args ...interface{})  {}
+func (dummy) Warnf(format string, args ...interface{})  {}
+func (dummy) Errorf(format string, args ...interface{}) {}
+func (dummy) Debugf(format string, args ...interface{}) {}
+func (d dummy) With(keyvals ...interface{}) Logger      { return d }
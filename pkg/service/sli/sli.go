@@ -0,0 +1,14 @@
+// Package sli measures the service level indicators configured on a
+// ServiceLevel's SLO and returns the raw counts so the slo package can
+// compute and expose ratios out of them.
+package sli
+
+// Result is the result of measuring an SLI at a given point in time. It
+// carries the raw event counts so the consumer (the slo package) can
+// accumulate them as it sees fit.
+type Result struct {
+	// TotalQ is the total quantity of events measured.
+	TotalQ float64
+	// ErrorQ is the quantity of events measured that are considered an error.
+	ErrorQ float64
+}
@@ -0,0 +1,246 @@
+package slo
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	measurev1alpha1 "github.com/slok/service-level-operator/pkg/apis/measure/v1alpha1"
+	"github.com/slok/service-level-operator/pkg/log"
+	"github.com/slok/service-level-operator/pkg/service/sli"
+)
+
+const (
+	defaultOTLPQueueSize  = 1000
+	defaultOTLPMaxRetries = 5
+	defaultOTLPBackoff    = 500 * time.Millisecond
+)
+
+// OTLPExporter knows how to push a slice of OTLP metrics to a collector,
+// satisfied by the OTLP gRPC and HTTP exporter clients.
+type OTLPExporter interface {
+	Export(ctx context.Context, metrics []*metricpb.Metric, resource *resourcepb.Resource) error
+}
+
+// OTLPCfg is the configuration of the OTLP output.
+type OTLPCfg struct {
+	// NormalizePrometheusNames drops the `_total` suffix and keeps the
+	// metric names snake_case, like OTel's Prometheus receiver does.
+	NormalizePrometheusNames bool
+	// QueueSize is the max number of pending SLO results kept in memory
+	// while the exporter retries, older ones are dropped once full.
+	QueueSize int
+	// MaxRetries is the number of retries (with exponential backoff)
+	// attempted before a batch is dropped.
+	MaxRetries int
+	// Backoff is the base backoff duration between retries.
+	Backoff time.Duration
+	// ExtraLabels are relabeling-style labels applied to every SLO exported
+	// by this output, regardless of the ServiceLevel/SLO they belong to, see
+	// PrometheusCfg.ExtraLabels.
+	ExtraLabels map[string]string
+}
+
+func (c *OTLPCfg) defaults() {
+	if c.QueueSize <= 0 {
+		c.QueueSize = defaultOTLPQueueSize
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = defaultOTLPMaxRetries
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = defaultOTLPBackoff
+	}
+}
+
+type otlpJob struct {
+	resource *resourcepb.Resource
+	metrics  []*metricpb.Metric
+}
+
+// otlpOutput is a slo.Output implementation that converts every SLI result
+// into OTLP metrics and pushes them to a collector through a bounded,
+// retrying in-memory queue.
+type otlpOutput struct {
+	cfg      OTLPCfg
+	exporter OTLPExporter
+	logger   log.Logger
+
+	// counters keeps the running cumulative sums used for the monotonic
+	// sums, keyed by ServiceLevel/SLO.
+	mu       sync.Mutex
+	counters map[string]*otlpCounters
+
+	queue chan otlpJob
+	done  chan struct{}
+}
+
+type otlpCounters struct {
+	totalQ float64
+	errorQ float64
+}
+
+// NewOTLP returns a new slo.Output that pushes the measured SLOs as OTLP
+// metrics through exporter. Call Close to stop the background worker once
+// it has drained whatever is still queued.
+func NewOTLP(cfg OTLPCfg, exporter OTLPExporter, logger log.Logger) Output {
+	cfg.defaults()
+
+	o := &otlpOutput{
+		cfg:      cfg,
+		exporter: exporter,
+		logger:   logger,
+		counters: map[string]*otlpCounters{},
+		queue:    make(chan otlpJob, cfg.QueueSize),
+		done:     make(chan struct{}),
+	}
+
+	go o.run()
+
+	return o
+}
+
+// Close satisfies slo.Closer. It stops accepting new work and waits for the
+// queue to drain before returning.
+func (o *otlpOutput) Close() error {
+	close(o.queue)
+	<-o.done
+	return nil
+}
+
+// Create satisfies slo.Output interface.
+func (o *otlpOutput) Create(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO, result *sli.Result) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	key := prometheusSLOKey(sl, slo)
+
+	c, ok := o.counters[key]
+	if !ok {
+		c = &otlpCounters{}
+		o.counters[key] = c
+	}
+	c.totalQ += result.TotalQ
+	c.errorQ += result.ErrorQ
+
+	now := time.Now().UnixNano()
+
+	job := otlpJob{
+		resource: o.resource(sl, slo),
+		metrics: []*metricpb.Metric{
+			o.sum(o.metricName("error_ratio_total"), c.errorQ, now),
+			o.sum(o.metricName("full_ratio_total"), c.totalQ, now),
+			o.gauge(o.metricName("objective_ratio"), slo.AvailabilityObjectivePercent/100, now),
+		},
+	}
+
+	select {
+	case o.queue <- job:
+	default:
+		// The queue is full, drop the oldest pending job to make room
+		// rather than blocking the caller.
+		select {
+		case <-o.queue:
+		default:
+		}
+		o.queue <- job
+	}
+
+	return nil
+}
+
+func (o *otlpOutput) run() {
+	defer close(o.done)
+
+	for job := range o.queue {
+		o.exportWithRetry(job)
+	}
+}
+
+func (o *otlpOutput) exportWithRetry(job otlpJob) {
+	backoff := o.cfg.Backoff
+
+	for attempt := 0; attempt <= o.cfg.MaxRetries; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := o.exporter.Export(ctx, job.metrics, job.resource)
+		cancel()
+		if err == nil {
+			return
+		}
+
+		o.logger.Warnf("could not export OTLP metrics (attempt %d/%d): %s", attempt+1, o.cfg.MaxRetries+1, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	o.logger.Errorf("dropping OTLP metrics batch after %d failed attempts", o.cfg.MaxRetries+1)
+}
+
+func (o *otlpOutput) metricName(name string) string {
+	if !o.cfg.NormalizePrometheusNames {
+		return promNamespace + "_" + promSubsystem + "_" + name
+	}
+	return strings.TrimSuffix(promNamespace+"_"+promSubsystem+"_"+name, "_total")
+}
+
+func (o *otlpOutput) resource(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO) *resourcepb.Resource {
+	commonLabels := commonSLOLabels(sl, o.cfg.ExtraLabels)
+
+	attrs := make([]*commonpb.KeyValue, 0, len(commonLabels)+3)
+	for _, k := range sortedLabelKeys(commonLabels) {
+		attrs = append(attrs, strAttr(k, commonLabels[k]))
+	}
+	attrs = append(attrs,
+		strAttr("service_level", sl.Name),
+		strAttr("namespace", sl.Namespace),
+		strAttr("slo", slo.Name),
+	)
+
+	return &resourcepb.Resource{Attributes: attrs}
+}
+
+func (o *otlpOutput) sum(name string, value float64, timestampNano int64) *metricpb.Metric {
+	return &metricpb.Metric{
+		Name: name,
+		Data: &metricpb.Metric_Sum{
+			Sum: &metricpb.Sum{
+				AggregationTemporality: metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+				DataPoints: []*metricpb.NumberDataPoint{
+					{
+						TimeUnixNano: uint64(timestampNano),
+						Value:        &metricpb.NumberDataPoint_AsDouble{AsDouble: value},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (o *otlpOutput) gauge(name string, value float64, timestampNano int64) *metricpb.Metric {
+	return &metricpb.Metric{
+		Name: name,
+		Data: &metricpb.Metric_Gauge{
+			Gauge: &metricpb.Gauge{
+				DataPoints: []*metricpb.NumberDataPoint{
+					{
+						TimeUnixNano: uint64(timestampNano),
+						Value:        &metricpb.NumberDataPoint_AsDouble{AsDouble: value},
+					},
+				},
+			},
+		},
+	}
+}
+
+func strAttr(k, v string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   k,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}},
+	}
+}
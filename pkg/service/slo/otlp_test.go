@@ -0,0 +1,90 @@
+package slo_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"github.com/slok/service-level-operator/pkg/log"
+	"github.com/slok/service-level-operator/pkg/service/sli"
+	"github.com/slok/service-level-operator/pkg/service/slo"
+)
+
+// fakeOTLPExporter fails the first failUntil calls and succeeds afterwards,
+// to exercise the output's retry/backoff behavior.
+type fakeOTLPExporter struct {
+	mu        sync.Mutex
+	failUntil int
+	calls     int
+	succeeded bool
+}
+
+func (f *fakeOTLPExporter) Export(ctx context.Context, metrics []*metricpb.Metric, resource *resourcepb.Resource) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	if f.calls <= f.failUntil {
+		return errors.New("collector unavailable")
+	}
+	f.succeeded = true
+	return nil
+}
+
+func (f *fakeOTLPExporter) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func (f *fakeOTLPExporter) hasSucceeded() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.succeeded
+}
+
+func TestOTLPOutputRetriesUntilItSucceeds(t *testing.T) {
+	assert := assert.New(t)
+
+	exporter := &fakeOTLPExporter{failUntil: 2}
+
+	output := slo.NewOTLP(slo.OTLPCfg{
+		MaxRetries: 5,
+		Backoff:    1 * time.Millisecond,
+	}, exporter, log.Dummy)
+
+	err := output.Create(sl0, slo00, &sli.Result{TotalQ: 100, ErrorQ: 1})
+	assert.NoError(err)
+
+	assert.Eventually(func() bool {
+		return exporter.hasSucceeded()
+	}, time.Second, 5*time.Millisecond, "the batch should eventually be exported once the collector recovers")
+	assert.GreaterOrEqual(exporter.callCount(), 3)
+
+	closer, ok := output.(slo.Closer)
+	assert.True(ok, "slo.NewOTLP should return a slo.Closer")
+	assert.NoError(closer.Close())
+}
+
+func TestOTLPOutputQueueDrainsOnClose(t *testing.T) {
+	assert := assert.New(t)
+
+	exporter := &fakeOTLPExporter{}
+
+	output := slo.NewOTLP(slo.OTLPCfg{QueueSize: 10}, exporter, log.Dummy)
+
+	for i := 0; i < 5; i++ {
+		err := output.Create(sl0, slo00, &sli.Result{TotalQ: 100, ErrorQ: 1})
+		assert.NoError(err)
+	}
+
+	closer := output.(slo.Closer)
+	assert.NoError(closer.Close())
+	assert.Equal(5, exporter.callCount())
+}
@@ -0,0 +1,394 @@
+package slo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	measurev1alpha1 "github.com/slok/service-level-operator/pkg/apis/measure/v1alpha1"
+	"github.com/slok/service-level-operator/pkg/log"
+	"github.com/slok/service-level-operator/pkg/service/sli"
+)
+
+const (
+	promNamespace = "service_level"
+	promSubsystem = "slo"
+
+	defaultExpireDuration = 24 * time.Hour
+)
+
+// staleNaN is the bit pattern Prometheus itself uses to mark a sample as
+// stale, see https://www.robustperception.io/staleness-and-promql.
+var staleNaN = math.Float64frombits(0x7ff0000000000002)
+
+// PrometheusCfg is the configuration of the Prometheus output.
+type PrometheusCfg struct {
+	// ExpireDuration is the duration a SLO's metrics will keep being exposed
+	// without receiving a new measurement before a staleness marker is
+	// emitted for them and they are removed from the registry.
+	ExpireDuration time.Duration
+	// ExtraLabels are relabeling-style labels applied to every SLO exposed
+	// by this output (e.g `cluster`, `region`, `env`), regardless of the
+	// ServiceLevel/SLO they belong to. Typically set from a controller-level
+	// `--extra-labels`/config-file flag.
+	ExtraLabels map[string]string
+	// BurnRateWindows are the rolling windows the output keeps error-budget
+	// burn rate gauges for (e.g 1h, 6h, 24h, 72h), so the standard SRE
+	// workbook multi-window multi-burn-rate alerts can be written directly
+	// against `service_level_slo_error_budget_burn_rate` without needing
+	// recording rules.
+	BurnRateWindows []time.Duration
+}
+
+var defaultBurnRateWindows = []time.Duration{
+	1 * time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	72 * time.Hour,
+}
+
+func (c *PrometheusCfg) defaults() {
+	if c.ExpireDuration <= 0 {
+		c.ExpireDuration = defaultExpireDuration
+	}
+	if len(c.BurnRateWindows) == 0 {
+		c.BurnRateWindows = defaultBurnRateWindows
+	}
+}
+
+// burnRateSample is a single observation kept on a series' rolling window
+// ring buffer, used to compute the multi-window burn rate gauges.
+type burnRateSample struct {
+	at     time.Time
+	totalQ float64
+	errorQ float64
+}
+
+// prometheusSeries holds the descriptors and accumulated counts of a single
+// ServiceLevel/SLO pair exposed on the collector.
+type prometheusSeries struct {
+	labels prometheus.Labels
+
+	errorRatioDesc *prometheus.Desc
+	fullRatioDesc  *prometheus.Desc
+	objectiveDesc  *prometheus.Desc
+	burnRateDescs  []*prometheus.Desc // one per PrometheusCfg.BurnRateWindows, same order
+
+	errorRatio float64 // sum of every measurement's errorQ/totalQ.
+	count      float64
+	objective  float64
+	lastUpdate time.Time
+
+	// samples is the ring buffer of raw (timestamp, totalQ, errorQ)
+	// observations kept to compute the burn rate windows, trimmed to the
+	// largest configured window on every Create.
+	samples   []burnRateSample
+	burnRates []float64 // one per PrometheusCfg.BurnRateWindows, same order
+
+	// staleSent is true once the staleness marker for this series has
+	// already been yielded on a Collect, it is removed on the next one.
+	staleSent bool
+}
+
+// rebuildDescs (re)builds the series' descriptors out of its current
+// labels, it must be called every time those labels change.
+func (p *prometheusOutput) rebuildDescs(s *prometheusSeries) {
+	s.errorRatioDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(promNamespace, promSubsystem, "error_ratio_total"),
+		"The cumulative error ratio of the SLO measurements.",
+		nil, s.labels,
+	)
+	s.fullRatioDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(promNamespace, promSubsystem, "full_ratio_total"),
+		"The total number of measurement windows received for the SLO.",
+		nil, s.labels,
+	)
+	s.objectiveDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(promNamespace, promSubsystem, "objective_ratio"),
+		"The availability objective of the SLO as a ratio (0-1).",
+		nil, s.labels,
+	)
+
+	s.burnRateDescs = make([]*prometheus.Desc, len(p.cfg.BurnRateWindows))
+	for i, w := range p.cfg.BurnRateWindows {
+		labels := prometheus.Labels{}
+		for k, v := range s.labels {
+			labels[k] = v
+		}
+		// window is the burn-rate discriminator and must win over any
+		// user-supplied label of the same name, the same way the identity
+		// labels always win in prometheusSLOLabels.
+		labels["window"] = formatBurnRateWindow(w)
+		s.burnRateDescs[i] = prometheus.NewDesc(
+			prometheus.BuildFQName(promNamespace, promSubsystem, "error_budget_burn_rate"),
+			"The error budget burn rate over the window, ready for the standard multi-window multi-burn-rate alerts.",
+			nil, labels,
+		)
+	}
+}
+
+// formatBurnRateWindow renders a window duration the way the SRE workbook
+// alerts expect it (e.g `1h`, `6h`, `1d`, `3d`).
+func formatBurnRateWindow(w time.Duration) string {
+	switch {
+	case w%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", w/(24*time.Hour))
+	case w%time.Hour == 0:
+		return fmt.Sprintf("%dh", w/time.Hour)
+	case w%time.Minute == 0:
+		return fmt.Sprintf("%dm", w/time.Minute)
+	default:
+		return w.String()
+	}
+}
+
+// prometheusOutput is a slo.Output implementation that exposes the SLO
+// results as Prometheus metrics through a single custom Collector. A custom
+// Collector (instead of one registered metric per series) is required
+// because every SLO can carry a different set of extra labels, and because
+// it lets us emit an explicit staleness marker for a series before removing
+// it, instead of letting it silently go missing.
+type prometheusOutput struct {
+	cfg    PrometheusCfg
+	logger log.Logger
+
+	mu        sync.Mutex
+	series    map[string]*prometheusSeries
+	labelKeys map[string]struct{}
+}
+
+// NewPrometheus returns a new slo.Output that exposes the measured SLOs as
+// Prometheus metrics on the given registry.
+func NewPrometheus(cfg PrometheusCfg, registry prometheus.Registerer, logger log.Logger) Output {
+	cfg.defaults()
+
+	p := &prometheusOutput{
+		cfg:       cfg,
+		logger:    logger,
+		series:    map[string]*prometheusSeries{},
+		labelKeys: map[string]struct{}{},
+	}
+
+	registry.MustRegister(p)
+
+	return p
+}
+
+// Describe satisfies prometheus.Collector. It intentionally sends no
+// descriptors, making this an unchecked collector: the set of label names
+// it exposes varies per SLO and can't be known upfront.
+func (p *prometheusOutput) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect satisfies prometheus.Collector. For every known series it yields
+// either its current value, a one-off staleness marker if it just expired,
+// or nothing if the staleness marker was already sent on a previous scrape.
+func (p *prometheusOutput) Collect(ch chan<- prometheus.Metric) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for key, s := range p.series {
+		if s.staleSent {
+			delete(p.series, key)
+			continue
+		}
+
+		if now.Sub(s.lastUpdate) > p.cfg.ExpireDuration {
+			ch <- prometheus.MustNewConstMetric(s.errorRatioDesc, prometheus.GaugeValue, staleNaN)
+			ch <- prometheus.MustNewConstMetric(s.fullRatioDesc, prometheus.GaugeValue, staleNaN)
+			ch <- prometheus.MustNewConstMetric(s.objectiveDesc, prometheus.GaugeValue, staleNaN)
+			for _, desc := range s.burnRateDescs {
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, staleNaN)
+			}
+			s.staleSent = true
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(s.errorRatioDesc, prometheus.GaugeValue, s.errorRatio)
+		ch <- prometheus.MustNewConstMetric(s.fullRatioDesc, prometheus.GaugeValue, s.count)
+		ch <- prometheus.MustNewConstMetric(s.objectiveDesc, prometheus.GaugeValue, s.objective)
+		for i, desc := range s.burnRateDescs {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, s.burnRates[i])
+		}
+	}
+}
+
+func prometheusSLOKey(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO) string {
+	return sl.Namespace + "/" + sl.Name + "/" + slo.Name
+}
+
+// commonSLOLabels merges (from least to most specific) a controller-level
+// extra labels map and the ServiceLevel's CommonLabels, the two label
+// sources shared by every output (Prometheus, StatsD, RemoteWrite, OTLP) so
+// the same SLO carries a consistent label/tag set regardless of which
+// backend it is fanned out to.
+func commonSLOLabels(sl *measurev1alpha1.ServiceLevel, extraLabels map[string]string) map[string]string {
+	labels := map[string]string{}
+
+	for k, v := range extraLabels {
+		labels[k] = v
+	}
+	for k, v := range sl.Spec.CommonLabels {
+		labels[k] = v
+	}
+
+	return labels
+}
+
+// sortedLabelKeys returns labels' keys in sorted order, so outputs that
+// render labels into an ordered list (e.g StatsD tags, OTLP attributes)
+// produce a deterministic result.
+func sortedLabelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// prometheusSLOLabels builds the raw label set for a SLO, merging (from
+// least to most specific) the controller-level extra labels, the
+// ServiceLevel's CommonLabels and the SLO's own Prometheus labels. The
+// identity labels (namespace/service_level/slo) always win over any of
+// them.
+func prometheusSLOLabels(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO, extraLabels map[string]string) prometheus.Labels {
+	labels := prometheus.Labels{}
+
+	for k, v := range commonSLOLabels(sl, extraLabels) {
+		labels[k] = v
+	}
+	if slo.Output.Prometheus != nil {
+		for k, v := range slo.Output.Prometheus.Labels {
+			labels[k] = v
+		}
+	}
+
+	labels["namespace"] = sl.Namespace
+	labels["service_level"] = sl.Name
+	labels["slo"] = slo.Name
+
+	return labels
+}
+
+// normalizeLabelKeys registers any label key not seen before on this output
+// and, when a new one shows up, backfills it (with an empty value) on every
+// already known series so all SLOs under the same operator instance keep a
+// consistent label set instead of producing fragmented series.
+func (p *prometheusOutput) normalizeLabelKeys(labels prometheus.Labels) {
+	newKey := false
+	for k := range labels {
+		if _, ok := p.labelKeys[k]; !ok {
+			p.labelKeys[k] = struct{}{}
+			newKey = true
+		}
+	}
+
+	if newKey {
+		for _, s := range p.series {
+			for k := range p.labelKeys {
+				if _, ok := s.labels[k]; !ok {
+					s.labels[k] = ""
+				}
+			}
+			p.rebuildDescs(s)
+		}
+	}
+
+	for k := range p.labelKeys {
+		if _, ok := labels[k]; !ok {
+			labels[k] = ""
+		}
+	}
+}
+
+// Create satisfies slo.Output interface.
+func (p *prometheusOutput) Create(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO, result *sli.Result) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := prometheusSLOKey(sl, slo)
+	s, ok := p.series[key]
+	if !ok {
+		labels := prometheusSLOLabels(sl, slo, p.cfg.ExtraLabels)
+		p.normalizeLabelKeys(labels)
+
+		s = &prometheusSeries{labels: labels}
+		p.rebuildDescs(s)
+		p.series[key] = s
+	}
+
+	now := time.Now()
+
+	if result.TotalQ > 0 {
+		s.errorRatio += result.ErrorQ / result.TotalQ
+	}
+	s.count++
+	s.objective = slo.AvailabilityObjectivePercent / 100
+	s.lastUpdate = now
+	s.staleSent = false
+
+	p.recordBurnRateSample(s, result, now)
+
+	return nil
+}
+
+// recordBurnRateSample appends the new observation to the series' ring
+// buffer, drops the ones that fell out of the largest configured window and
+// recomputes the burn rate for every configured window.
+func (p *prometheusOutput) recordBurnRateSample(s *prometheusSeries, result *sli.Result, now time.Time) {
+	s.samples = append(s.samples, burnRateSample{at: now, totalQ: result.TotalQ, errorQ: result.ErrorQ})
+
+	maxWindow := p.cfg.BurnRateWindows[0]
+	for _, w := range p.cfg.BurnRateWindows {
+		if w > maxWindow {
+			maxWindow = w
+		}
+	}
+	cutoff := now.Add(-maxWindow)
+	i := 0
+	for i < len(s.samples) && s.samples[i].at.Before(cutoff) {
+		i++
+	}
+	s.samples = s.samples[i:]
+
+	errorBudget := 1 - s.objective
+
+	s.burnRates = make([]float64, len(p.cfg.BurnRateWindows))
+	for i, w := range p.cfg.BurnRateWindows {
+		windowCutoff := now.Add(-w)
+
+		var totalQ, errorQ float64
+		for _, sample := range s.samples {
+			if sample.at.Before(windowCutoff) {
+				continue
+			}
+			totalQ += sample.totalQ
+			errorQ += sample.errorQ
+		}
+
+		if totalQ <= 0 || errorBudget <= 0 {
+			s.burnRates[i] = 0
+			continue
+		}
+		s.burnRates[i] = (errorQ / totalQ) / errorBudget
+	}
+}
+
+// Delete satisfies slo.Deleter. It's called when a ServiceLevel/SLO is
+// removed from the API, it forces the series to be treated as expired so
+// the next scrape emits its staleness marker instead of waiting for
+// ExpireDuration to pass.
+func (p *prometheusOutput) Delete(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := prometheusSLOKey(sl, slo)
+	if s, ok := p.series[key]; ok {
+		s.lastUpdate = time.Time{}
+	}
+}
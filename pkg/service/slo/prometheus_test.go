@@ -3,6 +3,7 @@ package slo_test
 import (
 	"io/ioutil"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
@@ -153,17 +154,21 @@ func TestPrometheusOutput(t *testing.T) {
 				})
 			},
 			expMetrics: []string{
-				`service_level_slo_error_ratio_total{namespace="ns0",service_level="sl0-test",slo="slo00-test"} 0.000122`,
-				`service_level_slo_full_ratio_total{namespace="ns0",service_level="sl0-test",slo="slo00-test"} 1`,
-				`service_level_slo_objective_ratio{namespace="ns0",service_level="sl0-test",slo="slo00-test"} 0.9999899999999999`,
+				// slo00/slo01/slo10 don't set their own Prometheus labels, but
+				// since slo11 (created on the same output) does, the `env`/
+				// `team` keys get backfilled with an empty value on every
+				// other SLO so all of them share a consistent label set.
+				`service_level_slo_error_ratio_total{env="",namespace="ns0",service_level="sl0-test",slo="slo00-test",team=""} 0.000122`,
+				`service_level_slo_full_ratio_total{env="",namespace="ns0",service_level="sl0-test",slo="slo00-test",team=""} 1`,
+				`service_level_slo_objective_ratio{env="",namespace="ns0",service_level="sl0-test",slo="slo00-test",team=""} 0.9999899999999999`,
 
-				`service_level_slo_error_ratio_total{namespace="ns0",service_level="sl0-test",slo="slo01-test"} 0.3363006923837784`,
-				`service_level_slo_full_ratio_total{namespace="ns0",service_level="sl0-test",slo="slo01-test"} 1`,
-				`service_level_slo_objective_ratio{namespace="ns0",service_level="sl0-test",slo="slo01-test"} 0.9998`,
+				`service_level_slo_error_ratio_total{env="",namespace="ns0",service_level="sl0-test",slo="slo01-test",team=""} 0.3363006923837784`,
+				`service_level_slo_full_ratio_total{env="",namespace="ns0",service_level="sl0-test",slo="slo01-test",team=""} 1`,
+				`service_level_slo_objective_ratio{env="",namespace="ns0",service_level="sl0-test",slo="slo01-test",team=""} 0.9998`,
 
-				`service_level_slo_error_ratio_total{namespace="ns1",service_level="sl1-test",slo="slo10-test"} 0.0009766096154773965`,
-				`service_level_slo_full_ratio_total{namespace="ns1",service_level="sl1-test",slo="slo10-test"} 2`,
-				`service_level_slo_objective_ratio{namespace="ns1",service_level="sl1-test",slo="slo10-test"} 0.9999978`,
+				`service_level_slo_error_ratio_total{env="",namespace="ns1",service_level="sl1-test",slo="slo10-test",team=""} 0.0009766096154773965`,
+				`service_level_slo_full_ratio_total{env="",namespace="ns1",service_level="sl1-test",slo="slo10-test",team=""} 2`,
+				`service_level_slo_objective_ratio{env="",namespace="ns1",service_level="sl1-test",slo="slo10-test",team=""} 0.9999978`,
 
 				`service_level_slo_error_ratio_total{env="test",namespace="ns1",service_level="sl1-test",slo="slo11-test",team="team1"} 0.0070140280561122245`,
 				`service_level_slo_full_ratio_total{env="test",namespace="ns1",service_level="sl1-test",slo="slo11-test",team="team1"} 1`,
@@ -196,3 +201,193 @@ func TestPrometheusOutput(t *testing.T) {
 		})
 	}
 }
+
+// scrapePrometheus renders the current state of promReg as the Prometheus
+// text exposition format, the same way a real scrape would.
+func scrapePrometheus(promReg *prometheus.Registry) string {
+	h := promhttp.HandlerFor(promReg, promhttp.HandlerOpts{})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	h.ServeHTTP(w, req)
+
+	metrics, _ := ioutil.ReadAll(w.Result().Body)
+	return string(metrics)
+}
+
+func TestPrometheusOutputStaleness(t *testing.T) {
+	assert := assert.New(t)
+	promReg := prometheus.NewRegistry()
+
+	output := slo.NewPrometheus(slo.PrometheusCfg{ExpireDuration: 500 * time.Microsecond}, promReg, log.Dummy)
+	output.Create(sl0, slo00, &sli.Result{
+		TotalQ: 1000000,
+		ErrorQ: 122,
+	})
+
+	metrics := scrapePrometheus(promReg)
+	assert.Contains(metrics, `service_level_slo_error_ratio_total{namespace="ns0",service_level="sl0-test",slo="slo00-test"} 0.000122`)
+
+	time.Sleep(1 * time.Millisecond)
+
+	// The first scrape after expiry must emit an explicit staleness marker
+	// for every series of the SLO, instead of silently dropping them.
+	metrics = scrapePrometheus(promReg)
+	assert.NotContains(metrics, `service_level_slo_error_ratio_total{namespace="ns0",service_level="sl0-test",slo="slo00-test"} 0.000122`)
+	assert.Contains(metrics, `service_level_slo_error_ratio_total{namespace="ns0",service_level="sl0-test",slo="slo00-test"} NaN`)
+	assert.Contains(metrics, `service_level_slo_full_ratio_total{namespace="ns0",service_level="sl0-test",slo="slo00-test"} NaN`)
+	assert.Contains(metrics, `service_level_slo_objective_ratio{namespace="ns0",service_level="sl0-test",slo="slo00-test"} NaN`)
+
+	// The scrape after that must not expose the series at all anymore.
+	metrics = scrapePrometheus(promReg)
+	assert.NotContains(metrics, `service_level_slo_error_ratio_total{namespace="ns0",service_level="sl0-test",slo="slo00-test"}`)
+	assert.NotContains(metrics, `service_level_slo_full_ratio_total{namespace="ns0",service_level="sl0-test",slo="slo00-test"}`)
+	assert.NotContains(metrics, `service_level_slo_objective_ratio{namespace="ns0",service_level="sl0-test",slo="slo00-test"}`)
+}
+
+func TestPrometheusOutputCommonAndExtraLabels(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       slo.PrometheusCfg
+		sl        *measurev1alpha1.ServiceLevel
+		slo       *measurev1alpha1.SLO
+		expMetric string
+	}{
+		{
+			name: "ServiceLevel.Spec.CommonLabels should be merged into the SLO's output",
+			sl: &measurev1alpha1.ServiceLevel{
+				ObjectMeta: metav1.ObjectMeta{Name: "sl-common", Namespace: "ns-common"},
+				Spec: measurev1alpha1.ServiceLevelSpec{
+					CommonLabels: map[string]string{"team": "payments"},
+				},
+			},
+			slo: &measurev1alpha1.SLO{
+				Name:                         "slo-common",
+				AvailabilityObjectivePercent: 99,
+				Output:                       measurev1alpha1.Output{Prometheus: &measurev1alpha1.PrometheusOutputSource{}},
+			},
+			expMetric: `service_level_slo_objective_ratio{namespace="ns-common",service_level="sl-common",slo="slo-common",team="payments"} 0.99`,
+		},
+		{
+			name: "Controller-level PrometheusCfg.ExtraLabels should be added to every SLO",
+			cfg:  slo.PrometheusCfg{ExtraLabels: map[string]string{"cluster": "prod"}},
+			sl: &measurev1alpha1.ServiceLevel{
+				ObjectMeta: metav1.ObjectMeta{Name: "sl-extra", Namespace: "ns-extra"},
+			},
+			slo: &measurev1alpha1.SLO{
+				Name:                         "slo-extra",
+				AvailabilityObjectivePercent: 95,
+				Output:                       measurev1alpha1.Output{Prometheus: &measurev1alpha1.PrometheusOutputSource{}},
+			},
+			expMetric: `service_level_slo_objective_ratio{cluster="prod",namespace="ns-extra",service_level="sl-extra",slo="slo-extra"} 0.95`,
+		},
+		{
+			name: "The identity labels should always win over ExtraLabels/CommonLabels/SLO labels trying to override them, which in turn override in that order",
+			cfg:  slo.PrometheusCfg{ExtraLabels: map[string]string{"namespace": "wrong-ns", "region": "eu-west-1"}},
+			sl: &measurev1alpha1.ServiceLevel{
+				ObjectMeta: metav1.ObjectMeta{Name: "sl-precedence", Namespace: "ns-precedence"},
+				Spec: measurev1alpha1.ServiceLevelSpec{
+					CommonLabels: map[string]string{"service_level": "wrong-sl"},
+				},
+			},
+			slo: &measurev1alpha1.SLO{
+				Name:                         "slo-precedence",
+				AvailabilityObjectivePercent: 90,
+				Output: measurev1alpha1.Output{
+					Prometheus: &measurev1alpha1.PrometheusOutputSource{
+						Labels: map[string]string{"slo": "wrong-slo", "region": "us-east-1"},
+					},
+				},
+			},
+			// region is set by both ExtraLabels and the SLO's own labels, the
+			// more specific (SLO) one must win; namespace/service_level/slo
+			// are identity labels and always win over any of them.
+			expMetric: `service_level_slo_objective_ratio{namespace="ns-precedence",region="us-east-1",service_level="sl-precedence",slo="slo-precedence"} 0.9`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			promReg := prometheus.NewRegistry()
+
+			output := slo.NewPrometheus(test.cfg, promReg, log.Dummy)
+			output.Create(test.sl, test.slo, &sli.Result{TotalQ: 100, ErrorQ: 1})
+
+			assert.Contains(scrapePrometheus(promReg), test.expMetric)
+		})
+	}
+}
+
+func TestPrometheusOutputBurnRate(t *testing.T) {
+	assert := assert.New(t)
+	promReg := prometheus.NewRegistry()
+
+	const (
+		shortWindow = 20 * time.Millisecond
+		longWindow  = 200 * time.Millisecond
+	)
+
+	sl := &measurev1alpha1.ServiceLevel{
+		ObjectMeta: metav1.ObjectMeta{Name: "sl-burn-test", Namespace: "ns-burn"},
+	}
+	slo99 := &measurev1alpha1.SLO{
+		Name:                         "slo-burn-test",
+		AvailabilityObjectivePercent: 99, // a clean 0.01 error budget.
+		Output:                       measurev1alpha1.Output{Prometheus: &measurev1alpha1.PrometheusOutputSource{}},
+	}
+
+	output := slo.NewPrometheus(slo.PrometheusCfg{
+		BurnRateWindows: []time.Duration{shortWindow, longWindow},
+	}, promReg, log.Dummy)
+
+	output.Create(sl, slo99, &sli.Result{TotalQ: 100, ErrorQ: 50})
+
+	time.Sleep(50 * time.Millisecond)
+
+	output.Create(sl, slo99, &sli.Result{TotalQ: 100, ErrorQ: 0})
+
+	metrics := scrapePrometheus(promReg)
+
+	errorBudget := 1 - slo99.AvailabilityObjectivePercent/100
+	shortBurnRate := strconv.FormatFloat((0.0/100)/errorBudget, 'g', -1, 64)
+	longBurnRate := strconv.FormatFloat((50.0/200)/errorBudget, 'g', -1, 64)
+
+	// shortWindow only sees the second sample (error ratio 0), the first one
+	// already fell out of it.
+	assert.Contains(metrics, `service_level_slo_error_budget_burn_rate{namespace="ns-burn",service_level="sl-burn-test",slo="slo-burn-test",window="20ms"} `+shortBurnRate)
+	// longWindow still sees both samples: (50+0)/(100+100) = 0.25 error
+	// ratio, divided by the error budget.
+	assert.Contains(metrics, `service_level_slo_error_budget_burn_rate{namespace="ns-burn",service_level="sl-burn-test",slo="slo-burn-test",window="200ms"} `+longBurnRate)
+}
+
+func TestPrometheusOutputBurnRateWindowLabelWinsOverUserLabel(t *testing.T) {
+	assert := assert.New(t)
+	promReg := prometheus.NewRegistry()
+
+	sl := &measurev1alpha1.ServiceLevel{
+		ObjectMeta: metav1.ObjectMeta{Name: "sl-window-label", Namespace: "ns-window-label"},
+	}
+	sloWithWindowLabel := &measurev1alpha1.SLO{
+		Name:                         "slo-window-label",
+		AvailabilityObjectivePercent: 99,
+		Output: measurev1alpha1.Output{
+			// A user-supplied "window" label must not collide with (and
+			// silently overwrite) the burn-rate window discriminator.
+			Prometheus: &measurev1alpha1.PrometheusOutputSource{
+				Labels: map[string]string{"window": "user-value"},
+			},
+		},
+	}
+
+	output := slo.NewPrometheus(slo.PrometheusCfg{
+		BurnRateWindows: []time.Duration{1 * time.Hour, 6 * time.Hour},
+	}, promReg, log.Dummy)
+
+	output.Create(sl, sloWithWindowLabel, &sli.Result{TotalQ: 100, ErrorQ: 1})
+
+	metrics := scrapePrometheus(promReg)
+
+	assert.Contains(metrics, `service_level_slo_error_budget_burn_rate{namespace="ns-window-label",service_level="sl-window-label",slo="slo-window-label",window="1h"}`)
+	assert.Contains(metrics, `service_level_slo_error_budget_burn_rate{namespace="ns-window-label",service_level="sl-window-label",slo="slo-window-label",window="6h"}`)
+	assert.NotContains(metrics, `service_level_slo_error_budget_burn_rate{namespace="ns-window-label",service_level="sl-window-label",slo="slo-window-label",window="user-value"}`)
+}
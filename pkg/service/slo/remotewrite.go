@@ -0,0 +1,269 @@
+package slo
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+
+	measurev1alpha1 "github.com/slok/service-level-operator/pkg/apis/measure/v1alpha1"
+	"github.com/slok/service-level-operator/pkg/log"
+	"github.com/slok/service-level-operator/pkg/service/sli"
+)
+
+const (
+	remoteWriteErrorRatioMetric = promNamespace + "_" + promSubsystem + "_error_ratio_total"
+	remoteWriteFullRatioMetric  = promNamespace + "_" + promSubsystem + "_full_ratio_total"
+	remoteWriteObjectiveMetric  = promNamespace + "_" + promSubsystem + "_objective_ratio"
+
+	defaultRemoteWriteFlushInterval = 15 * time.Second
+	defaultRemoteWriteBatchSize     = 500
+)
+
+// RemoteWriteCfg is the configuration of the Prometheus Remote Write output.
+type RemoteWriteCfg struct {
+	// URL is the remote-write endpoint.
+	URL string
+	// BasicAuthUser/BasicAuthPassword set HTTP basic auth on every request,
+	// if BasicAuthUser is not empty.
+	BasicAuthUser     string
+	BasicAuthPassword string
+	// BearerToken, if set, is sent as an `Authorization: Bearer` header.
+	BearerToken string
+	// TLSConfig is the TLS configuration used by the HTTP client.
+	TLSConfig *tls.Config
+	// FlushInterval is how often the batched samples are written to the
+	// remote-write endpoint, defaults to 15s.
+	FlushInterval time.Duration
+	// BatchSize is the number of samples batched before forcing a flush,
+	// defaults to 500.
+	BatchSize int
+	// ExtraLabels are relabeling-style labels applied to every SLO shipped
+	// by this output, regardless of the ServiceLevel/SLO they belong to, see
+	// PrometheusCfg.ExtraLabels.
+	ExtraLabels map[string]string
+
+	// HTTPClient is the client used to perform the write requests, defaults
+	// to a client using TLSConfig.
+	HTTPClient *http.Client
+}
+
+func (c *RemoteWriteCfg) defaults() {
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = defaultRemoteWriteFlushInterval
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultRemoteWriteBatchSize
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: c.TLSConfig},
+			Timeout:   10 * time.Second,
+		}
+	}
+}
+
+// remoteWriteCounts keeps the running cumulative totals of a SLO, so the
+// shipped samples reflect the SLO's lifetime values (the error ratio is the
+// summed errorQ over the summed totalQ) instead of a single raw Create call.
+type remoteWriteCounts struct {
+	totalQ float64
+	errorQ float64
+	count  float64
+}
+
+// remoteWriteOutput is a slo.Output implementation that batches the SLO
+// measurements and ships them to a Prometheus remote-write endpoint.
+type remoteWriteOutput struct {
+	cfg    RemoteWriteCfg
+	logger log.Logger
+
+	mu      sync.Mutex
+	samples []prompb.TimeSeries
+	counts  map[string]*remoteWriteCounts
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRemoteWrite returns a new slo.Output that batches every SLO
+// measurement and ships them to a Prometheus remote-write endpoint. Call
+// Close to stop the background flush loop and flush any pending samples.
+func NewRemoteWrite(cfg RemoteWriteCfg, logger log.Logger) Output {
+	cfg.defaults()
+
+	r := &remoteWriteOutput{
+		cfg:    cfg,
+		logger: logger,
+		counts: map[string]*remoteWriteCounts{},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go r.flushLoop()
+
+	return r
+}
+
+// Close satisfies slo.Closer. It stops the background flush loop after
+// flushing any samples still pending.
+func (r *remoteWriteOutput) Close() error {
+	close(r.stop)
+	<-r.done
+	return nil
+}
+
+// Create satisfies slo.Output interface, it batches the resulting samples
+// instead of writing them straight away.
+func (r *remoteWriteOutput) Create(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO, result *sli.Result) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	labels := remoteWriteLabels(sl, slo, r.cfg.ExtraLabels)
+	now := timestampMs()
+
+	key := prometheusSLOKey(sl, slo)
+	c, ok := r.counts[key]
+	if !ok {
+		c = &remoteWriteCounts{}
+		r.counts[key] = c
+	}
+	c.totalQ += result.TotalQ
+	c.errorQ += result.ErrorQ
+	c.count++
+
+	errorRatio := float64(0)
+	if c.totalQ > 0 {
+		errorRatio = c.errorQ / c.totalQ
+	}
+
+	r.samples = append(r.samples,
+		remoteWriteSeries(remoteWriteErrorRatioMetric, labels, errorRatio, now),
+		remoteWriteSeries(remoteWriteFullRatioMetric, labels, c.count, now),
+		remoteWriteSeries(remoteWriteObjectiveMetric, labels, slo.AvailabilityObjectivePercent/100, now),
+	)
+
+	if len(r.samples) >= r.cfg.BatchSize {
+		return r.flush()
+	}
+
+	return nil
+}
+
+func (r *remoteWriteOutput) flushLoop() {
+	defer close(r.done)
+
+	t := time.NewTicker(r.cfg.FlushInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			r.mu.Lock()
+			if err := r.flush(); err != nil {
+				r.logger.Errorf("could not remote-write SLO samples: %s", err)
+			}
+			r.mu.Unlock()
+		case <-r.stop:
+			r.mu.Lock()
+			if err := r.flush(); err != nil {
+				r.logger.Errorf("could not remote-write SLO samples: %s", err)
+			}
+			r.mu.Unlock()
+			return
+		}
+	}
+}
+
+// flush writes the currently batched samples, the caller must hold r.mu.
+func (r *remoteWriteOutput) flush() error {
+	if len(r.samples) == 0 {
+		return nil
+	}
+
+	req := &prompb.WriteRequest{
+		Timeseries: r.samples,
+		Metadata: []prompb.MetricMetadata{
+			{Type: prompb.MetricMetadata_GAUGE, MetricFamilyName: remoteWriteErrorRatioMetric, Help: "The cumulative error ratio of the SLO measurements."},
+			{Type: prompb.MetricMetadata_COUNTER, MetricFamilyName: remoteWriteFullRatioMetric, Help: "The total number of measurement windows received for the SLO."},
+			{Type: prompb.MetricMetadata_GAUGE, MetricFamilyName: remoteWriteObjectiveMetric, Help: "The availability objective of the SLO as a ratio (0-1)."},
+		},
+	}
+
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	compressed := snappy.Encode(nil, data)
+
+	if err := r.send(compressed); err != nil {
+		return err
+	}
+
+	r.samples = r.samples[:0]
+
+	return nil
+}
+
+func (r *remoteWriteOutput) send(compressed []byte) error {
+	httpReq, err := http.NewRequest(http.MethodPost, r.cfg.URL, bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	if r.cfg.BasicAuthUser != "" {
+		httpReq.SetBasicAuth(r.cfg.BasicAuthUser, r.cfg.BasicAuthPassword)
+	}
+	if r.cfg.BearerToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+r.cfg.BearerToken)
+	}
+
+	resp, err := r.cfg.HTTPClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("remote-write endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+func remoteWriteLabels(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO, extraLabels map[string]string) []prompb.Label {
+	labels := prometheusSLOLabels(sl, slo, extraLabels)
+
+	pbLabels := make([]prompb.Label, 0, len(labels))
+	for k, v := range labels {
+		pbLabels = append(pbLabels, prompb.Label{Name: k, Value: v})
+	}
+
+	return pbLabels
+}
+
+func remoteWriteSeries(metric string, labels []prompb.Label, value float64, timestampMs int64) prompb.TimeSeries {
+	series := append([]prompb.Label{{Name: "__name__", Value: metric}}, labels...)
+
+	return prompb.TimeSeries{
+		Labels: series,
+		Samples: []prompb.Sample{
+			{Value: value, Timestamp: timestampMs},
+		},
+	}
+}
+
+func timestampMs() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,174 @@
+package slo_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	measurev1alpha1 "github.com/slok/service-level-operator/pkg/apis/measure/v1alpha1"
+	"github.com/slok/service-level-operator/pkg/log"
+	"github.com/slok/service-level-operator/pkg/service/sli"
+	"github.com/slok/service-level-operator/pkg/service/slo"
+)
+
+func TestRemoteWriteOutput(t *testing.T) {
+	assert := assert.New(t)
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first request fails, to check the batch is kept (not
+		// dropped) and retried on the next flush.
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	output := slo.NewRemoteWrite(slo.RemoteWriteCfg{
+		URL:           srv.URL,
+		FlushInterval: 5 * time.Millisecond,
+		BatchSize:     100,
+	}, log.Dummy)
+
+	err := output.Create(sl0, slo00, &sli.Result{TotalQ: 100, ErrorQ: 1})
+	assert.NoError(err)
+
+	assert.Eventually(func() bool {
+		return atomic.LoadInt32(&requests) >= 2
+	}, time.Second, 5*time.Millisecond, "the failed batch should have been retried on a later flush")
+
+	closer, ok := output.(slo.Closer)
+	assert.True(ok, "slo.NewRemoteWrite should return a slo.Closer")
+	assert.NoError(closer.Close())
+}
+
+func TestRemoteWriteOutputClosePendingFlush(t *testing.T) {
+	assert := assert.New(t)
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	output := slo.NewRemoteWrite(slo.RemoteWriteCfg{
+		URL:           srv.URL,
+		FlushInterval: time.Hour, // never ticks on its own during the test.
+		BatchSize:     100,
+	}, log.Dummy)
+
+	err := output.Create(sl0, slo00, &sli.Result{TotalQ: 100, ErrorQ: 1})
+	assert.NoError(err)
+
+	// Close must flush the still-pending batch before returning, instead of
+	// waiting for the next tick (an hour from now).
+	closer := output.(slo.Closer)
+	assert.NoError(closer.Close())
+	assert.Equal(int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestRemoteWriteOutputCommonAndExtraLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	var received prompb.WriteRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		decompressed, err := snappy.Decode(nil, body)
+		assert.NoError(err)
+		assert.NoError(proto.Unmarshal(decompressed, &received))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	sl := &measurev1alpha1.ServiceLevel{
+		ObjectMeta: metav1.ObjectMeta{Name: "sl-rw-labels", Namespace: "ns-rw-labels"},
+		Spec: measurev1alpha1.ServiceLevelSpec{
+			CommonLabels: map[string]string{"team": "payments"},
+		},
+	}
+	theSLO := &measurev1alpha1.SLO{
+		Name:                         "slo-rw-labels",
+		AvailabilityObjectivePercent: 99,
+	}
+
+	output := slo.NewRemoteWrite(slo.RemoteWriteCfg{
+		URL:           srv.URL,
+		FlushInterval: time.Hour,
+		BatchSize:     1,
+		ExtraLabels:   map[string]string{"cluster": "prod"},
+	}, log.Dummy)
+
+	err := output.Create(sl, theSLO, &sli.Result{TotalQ: 100, ErrorQ: 1})
+	assert.NoError(err)
+
+	labels := map[string]string{}
+	for _, series := range received.Timeseries {
+		for _, l := range series.Labels {
+			labels[l.Name] = l.Value
+		}
+	}
+	assert.Equal("prod", labels["cluster"])
+	assert.Equal("payments", labels["team"])
+}
+
+func TestRemoteWriteOutputCumulativeValues(t *testing.T) {
+	assert := assert.New(t)
+
+	var received prompb.WriteRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		decompressed, err := snappy.Decode(nil, body)
+		assert.NoError(err)
+		assert.NoError(proto.Unmarshal(decompressed, &received))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	output := slo.NewRemoteWrite(slo.RemoteWriteCfg{
+		URL:           srv.URL,
+		FlushInterval: time.Hour, // never ticks on its own during the test.
+		BatchSize:     100,       // keep both calls in the same flush.
+	}, log.Dummy)
+
+	err := output.Create(sl0, slo00, &sli.Result{TotalQ: 100, ErrorQ: 50})
+	assert.NoError(err)
+	err = output.Create(sl0, slo00, &sli.Result{TotalQ: 100, ErrorQ: 0})
+	assert.NoError(err)
+
+	closer := output.(slo.Closer)
+	assert.NoError(closer.Close())
+
+	var errorRatios, fullRatios []float64
+	for _, series := range received.Timeseries {
+		for _, l := range series.Labels {
+			if l.Name != "__name__" {
+				continue
+			}
+			switch l.Value {
+			case "service_level_slo_error_ratio_total":
+				errorRatios = append(errorRatios, series.Samples[0].Value)
+			case "service_level_slo_full_ratio_total":
+				fullRatios = append(fullRatios, series.Samples[0].Value)
+			}
+		}
+	}
+
+	// The second call must reflect the cumulative totals (errorQ=50,
+	// totalQ=200 -> ratio 0.25, 2 measurement windows received), not the raw
+	// per-call result (which would have given a ratio of 0 and a raw TotalQ
+	// of 100).
+	assert.Equal([]float64{0.5, 0.25}, errorRatios)
+	assert.Equal([]float64{1, 2}, fullRatios)
+}
@@ -0,0 +1,77 @@
+// Package slo knows how to take SLI measurement results and expose them
+// on one or more outputs (Prometheus, StatsD...) as the ratios required to
+// track an SLO.
+package slo
+
+import (
+	multierror "github.com/hashicorp/go-multierror"
+
+	measurev1alpha1 "github.com/slok/service-level-operator/pkg/apis/measure/v1alpha1"
+	"github.com/slok/service-level-operator/pkg/service/sli"
+)
+
+// Output knows how to expose a SLO measurement result to a 3rd party system
+// (e.g Prometheus, StatsD...).
+type Output interface {
+	// Create creates/updates the required metrics for the SLO measurement
+	// result on the output backend.
+	Create(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO, result *sli.Result) error
+}
+
+// Deleter is implemented by outputs that keep per-SLO state and need to be
+// notified when a ServiceLevel/SLO is removed, so they can stop exposing
+// its last known result instead of leaving it behind until it expires.
+type Deleter interface {
+	Delete(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO)
+}
+
+// Closer is implemented by outputs that run a background goroutine (e.g to
+// batch or retry deliveries) and need to flush any pending state and stop it
+// on shutdown/reconfiguration.
+type Closer interface {
+	Close() error
+}
+
+// MultiOutput is an Output that fans out every Create call to all the
+// wrapped outputs, so the same result can be shipped to more than one
+// backend (e.g Prometheus and StatsD at the same time).
+type MultiOutput []Output
+
+// Create satisfies Output interface. It dispatches to every wrapped output
+// unconditionally, so a failure on one (e.g a network-backed one) doesn't
+// stop the result from reaching the rest, and aggregates any errors.
+func (m MultiOutput) Create(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO, result *sli.Result) error {
+	var errs *multierror.Error
+	for _, output := range m {
+		if err := output.Create(sl, slo, result); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// Delete satisfies Deleter, forwarding the deletion to every wrapped output
+// that implements it.
+func (m MultiOutput) Delete(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO) {
+	for _, output := range m {
+		if d, ok := output.(Deleter); ok {
+			d.Delete(sl, slo)
+		}
+	}
+}
+
+// Close satisfies Closer, forwarding the close to every wrapped output that
+// implements it and aggregating any errors, so the caller only needs to
+// Close the MultiOutput itself to shut down every backgrounded output it
+// wraps.
+func (m MultiOutput) Close() error {
+	var errs *multierror.Error
+	for _, output := range m {
+		if c, ok := output.(Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+	}
+	return errs.ErrorOrNil()
+}
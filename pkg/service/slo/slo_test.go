@@ -0,0 +1,145 @@
+package slo_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	measurev1alpha1 "github.com/slok/service-level-operator/pkg/apis/measure/v1alpha1"
+	"github.com/slok/service-level-operator/pkg/service/sli"
+	"github.com/slok/service-level-operator/pkg/service/slo"
+)
+
+// fakeOutput is a slo.Output test double that optionally also implements
+// slo.Deleter/slo.Closer, recording every call it receives.
+type fakeOutput struct {
+	createErr error
+	closeErr  error
+
+	createCalls int
+	deleteCalls int
+	closeCalls  int
+}
+
+func (f *fakeOutput) Create(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO, result *sli.Result) error {
+	f.createCalls++
+	return f.createErr
+}
+
+type deletingFakeOutput struct{ *fakeOutput }
+
+func (f *deletingFakeOutput) Delete(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO) {
+	f.deleteCalls++
+}
+
+type closingFakeOutput struct{ *fakeOutput }
+
+func (f *closingFakeOutput) Close() error {
+	f.closeCalls++
+	return f.closeErr
+}
+
+func TestMultiOutputCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs func() (slo.MultiOutput, []*fakeOutput)
+		expErr  bool
+	}{
+		{
+			name: "a single failing output should be reported and not prevent the others from receiving the result",
+			outputs: func() (slo.MultiOutput, []*fakeOutput) {
+				ok := &fakeOutput{}
+				failing := &fakeOutput{createErr: errors.New("network down")}
+				return slo.MultiOutput{failing, ok}, []*fakeOutput{failing, ok}
+			},
+			expErr: true,
+		},
+		{
+			name: "no failing outputs should not return an error",
+			outputs: func() (slo.MultiOutput, []*fakeOutput) {
+				a := &fakeOutput{}
+				b := &fakeOutput{}
+				return slo.MultiOutput{a, b}, []*fakeOutput{a, b}
+			},
+			expErr: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			multi, fakes := test.outputs()
+			err := multi.Create(sl0, slo00, &sli.Result{TotalQ: 100, ErrorQ: 1})
+
+			if test.expErr {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+
+			for _, f := range fakes {
+				assert.Equal(1, f.createCalls, "every wrapped output should have been dispatched to, regardless of the others' errors")
+			}
+		})
+	}
+}
+
+func TestMultiOutputDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	deleter := &deletingFakeOutput{&fakeOutput{}}
+	plain := &fakeOutput{}
+
+	multi := slo.MultiOutput{deleter, plain}
+	multi.Delete(sl0, slo00)
+
+	assert.Equal(1, deleter.deleteCalls)
+}
+
+func TestMultiOutputClose(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs func() (slo.MultiOutput, []*closingFakeOutput)
+		expErr  bool
+	}{
+		{
+			name: "Close should forward to every wrapped output that implements Closer",
+			outputs: func() (slo.MultiOutput, []*closingFakeOutput) {
+				a := &closingFakeOutput{&fakeOutput{}}
+				b := &closingFakeOutput{&fakeOutput{}}
+				return slo.MultiOutput{a, b, &fakeOutput{}}, []*closingFakeOutput{a, b}
+			},
+			expErr: false,
+		},
+		{
+			name: "a single failing Close should be reported and not prevent the others from closing",
+			outputs: func() (slo.MultiOutput, []*closingFakeOutput) {
+				ok := &closingFakeOutput{&fakeOutput{}}
+				failing := &closingFakeOutput{&fakeOutput{closeErr: errors.New("flush failed")}}
+				return slo.MultiOutput{failing, ok}, []*closingFakeOutput{failing, ok}
+			},
+			expErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			multi, closers := test.outputs()
+			err := multi.Close()
+
+			if test.expErr {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+
+			for _, c := range closers {
+				assert.Equal(1, c.closeCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package slo
+
+import (
+	"fmt"
+	"sync"
+
+	measurev1alpha1 "github.com/slok/service-level-operator/pkg/apis/measure/v1alpha1"
+	"github.com/slok/service-level-operator/pkg/log"
+	"github.com/slok/service-level-operator/pkg/service/sli"
+)
+
+// StatsDClient is the subset of a StatsD/DogStatsD client the output needs,
+// satisfied by `github.com/DataDog/datadog-go/statsd.Client`.
+type StatsDClient interface {
+	Gauge(name string, value float64, tags []string, rate float64) error
+	Count(name string, value int64, tags []string, rate float64) error
+}
+
+// StatsDCfg is the configuration of the StatsD/DogStatsD output.
+type StatsDCfg struct {
+	// Prefix is prepended (with a trailing dot) to every metric name.
+	Prefix string
+	// SampleRate is the sample rate (0-1) used on every metric sent,
+	// defaults to 1 (no sampling).
+	SampleRate float64
+	// Tags are extra tags added to every metric sent by this output.
+	Tags []string
+	// ExtraLabels are relabeling-style labels (rendered as `key:value` tags)
+	// applied to every SLO exposed by this output, regardless of the
+	// ServiceLevel/SLO they belong to. Typically set from a controller-level
+	// `--extra-labels`/config-file flag, see PrometheusCfg.ExtraLabels.
+	ExtraLabels map[string]string
+}
+
+func (c *StatsDCfg) defaults() {
+	if c.SampleRate <= 0 {
+		c.SampleRate = 1
+	}
+}
+
+// statsDCounts accumulates the fractional remainder of a SLO's error/total
+// counts between Create calls, since StatsD counters only take integers and
+// a SLI result can carry fractional quantities (e.g weighted/sampled SLIs).
+type statsDCounts struct {
+	errorQRemainder float64
+	totalQRemainder float64
+}
+
+// statsDOutput is a slo.Output implementation that ships the SLO results to
+// a StatsD/DogStatsD daemon, mogrifying the ratio metrics the Prometheus
+// output exposes into gauges/counters with tags.
+type statsDOutput struct {
+	cfg    StatsDCfg
+	client StatsDClient
+	logger log.Logger
+
+	mu     sync.Mutex
+	counts map[string]*statsDCounts
+}
+
+// NewStatsD returns a new slo.Output that ships the measured SLOs to a
+// StatsD/DogStatsD daemon through client.
+func NewStatsD(cfg StatsDCfg, client StatsDClient, logger log.Logger) Output {
+	cfg.defaults()
+
+	return &statsDOutput{
+		cfg:    cfg,
+		client: client,
+		logger: logger,
+		counts: map[string]*statsDCounts{},
+	}
+}
+
+// Create satisfies slo.Output interface.
+func (s *statsDOutput) Create(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO, result *sli.Result) error {
+	tags := s.tags(sl, slo)
+
+	errorRatio := float64(0)
+	if result.TotalQ > 0 {
+		errorRatio = result.ErrorQ / result.TotalQ
+	}
+
+	errorCount, totalCount := s.integerCounts(sl, slo, result)
+
+	if err := s.client.Count(s.metric("error_ratio_total"), errorCount, tags, s.cfg.SampleRate); err != nil {
+		return err
+	}
+	if err := s.client.Count(s.metric("full_ratio_total"), totalCount, tags, s.cfg.SampleRate); err != nil {
+		return err
+	}
+	if err := s.client.Gauge(s.metric("error_ratio"), errorRatio, tags, s.cfg.SampleRate); err != nil {
+		return err
+	}
+	if err := s.client.Gauge(s.metric("objective_ratio"), slo.AvailabilityObjectivePercent/100, tags, s.cfg.SampleRate); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// integerCounts converts result's fractional counts into the integer counts
+// sent to StatsD, carrying any sub-1 remainder over to the next Create call
+// instead of truncating it away.
+func (s *statsDOutput) integerCounts(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO, result *sli.Result) (errorCount, totalCount int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := prometheusSLOKey(sl, slo)
+	c, ok := s.counts[key]
+	if !ok {
+		c = &statsDCounts{}
+		s.counts[key] = c
+	}
+
+	c.errorQRemainder += result.ErrorQ
+	c.totalQRemainder += result.TotalQ
+
+	errorCount = int64(c.errorQRemainder)
+	totalCount = int64(c.totalQRemainder)
+
+	c.errorQRemainder -= float64(errorCount)
+	c.totalQRemainder -= float64(totalCount)
+
+	return errorCount, totalCount
+}
+
+func (s *statsDOutput) metric(name string) string {
+	if s.cfg.Prefix == "" {
+		return name
+	}
+	return fmt.Sprintf("%s.%s", s.cfg.Prefix, name)
+}
+
+// tags builds the per-SLO DogStatsD tags, merging (from least to most
+// specific) the controller-level ExtraLabels, the ServiceLevel's
+// CommonLabels, the SLO's own StatsD tags and the configured extra tags,
+// with the SLO identifying ones always present.
+func (s *statsDOutput) tags(sl *measurev1alpha1.ServiceLevel, slo *measurev1alpha1.SLO) []string {
+	commonLabels := commonSLOLabels(sl, s.cfg.ExtraLabels)
+
+	tags := make([]string, 0, len(commonLabels)+len(s.cfg.Tags)+3)
+	tags = append(tags,
+		fmt.Sprintf("namespace:%s", sl.Namespace),
+		fmt.Sprintf("service_level:%s", sl.Name),
+		fmt.Sprintf("slo:%s", slo.Name),
+	)
+
+	for _, k := range sortedLabelKeys(commonLabels) {
+		tags = append(tags, fmt.Sprintf("%s:%s", k, commonLabels[k]))
+	}
+
+	if slo.Output.StatsD != nil {
+		tags = append(tags, slo.Output.StatsD.Tags...)
+	}
+
+	return append(tags, s.cfg.Tags...)
+}
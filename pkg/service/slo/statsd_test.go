@@ -0,0 +1,125 @@
+package slo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	measurev1alpha1 "github.com/slok/service-level-operator/pkg/apis/measure/v1alpha1"
+	"github.com/slok/service-level-operator/pkg/log"
+	"github.com/slok/service-level-operator/pkg/service/sli"
+	"github.com/slok/service-level-operator/pkg/service/slo"
+)
+
+type statsDGauge struct {
+	name  string
+	value float64
+	tags  []string
+}
+
+type statsDCount struct {
+	name  string
+	value int64
+	tags  []string
+}
+
+// fakeStatsDClient is a slo.StatsDClient test double that records every call
+// it receives.
+type fakeStatsDClient struct {
+	gauges []statsDGauge
+	counts []statsDCount
+}
+
+func (f *fakeStatsDClient) Gauge(name string, value float64, tags []string, rate float64) error {
+	f.gauges = append(f.gauges, statsDGauge{name, value, tags})
+	return nil
+}
+
+func (f *fakeStatsDClient) Count(name string, value int64, tags []string, rate float64) error {
+	f.counts = append(f.counts, statsDCount{name, value, tags})
+	return nil
+}
+
+func TestStatsDOutputCreate(t *testing.T) {
+	assert := assert.New(t)
+	client := &fakeStatsDClient{}
+
+	output := slo.NewStatsD(slo.StatsDCfg{Prefix: "myapp"}, client, log.Dummy)
+
+	err := output.Create(sl0, slo00, &sli.Result{TotalQ: 100, ErrorQ: 25})
+	assert.NoError(err)
+
+	identityTags := []string{"namespace:ns0", "service_level:sl0-test", "slo:slo00-test"}
+
+	assert.Contains(client.counts, statsDCount{"myapp.error_ratio_total", 25, identityTags})
+	assert.Contains(client.counts, statsDCount{"myapp.full_ratio_total", 100, identityTags})
+	assert.Contains(client.gauges, statsDGauge{"myapp.error_ratio", 0.25, identityTags})
+	assert.Contains(client.gauges, statsDGauge{"myapp.objective_ratio", slo00.AvailabilityObjectivePercent / 100, identityTags})
+}
+
+func TestStatsDOutputFractionalCountCarryOver(t *testing.T) {
+	assert := assert.New(t)
+	client := &fakeStatsDClient{}
+
+	output := slo.NewStatsD(slo.StatsDCfg{}, client, log.Dummy)
+
+	// Each call carries a sub-1 fractional count (e.g a weighted/sampled
+	// SLI), none of it should be silently truncated away: after 4 calls of
+	// 0.6 the accumulated integer counts sent should total 2 (0.6*4 = 2.4).
+	for i := 0; i < 4; i++ {
+		err := output.Create(sl0, slo00, &sli.Result{TotalQ: 0.6, ErrorQ: 0.6})
+		assert.NoError(err)
+	}
+
+	var totalSent, errorSent int64
+	for _, c := range client.counts {
+		switch c.name {
+		case "full_ratio_total":
+			totalSent += c.value
+		case "error_ratio_total":
+			errorSent += c.value
+		}
+	}
+
+	assert.Equal(int64(2), totalSent)
+	assert.Equal(int64(2), errorSent)
+}
+
+func TestStatsDOutputCommonAndExtraLabels(t *testing.T) {
+	assert := assert.New(t)
+	client := &fakeStatsDClient{}
+
+	sl := &measurev1alpha1.ServiceLevel{
+		ObjectMeta: metav1.ObjectMeta{Name: "sl-statsd-labels", Namespace: "ns-statsd-labels"},
+		Spec: measurev1alpha1.ServiceLevelSpec{
+			CommonLabels: map[string]string{"team": "payments"},
+		},
+	}
+	theSLO := &measurev1alpha1.SLO{
+		Name:                         "slo-statsd-labels",
+		AvailabilityObjectivePercent: 99,
+		Output: measurev1alpha1.Output{
+			StatsD: &measurev1alpha1.StatsDOutputSource{Tags: []string{"pod:web-1"}},
+		},
+	}
+
+	output := slo.NewStatsD(slo.StatsDCfg{
+		ExtraLabels: map[string]string{"cluster": "prod"},
+	}, client, log.Dummy)
+
+	err := output.Create(sl, theSLO, &sli.Result{TotalQ: 100, ErrorQ: 1})
+	assert.NoError(err)
+
+	expTags := []string{
+		"namespace:ns-statsd-labels",
+		"service_level:sl-statsd-labels",
+		"slo:slo-statsd-labels",
+		"cluster:prod",
+		"team:payments",
+		"pod:web-1",
+	}
+
+	assert.NotEmpty(client.gauges)
+	assert.Equal(expTags, client.gauges[0].tags)
+}